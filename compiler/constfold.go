@@ -0,0 +1,80 @@
+package compiler
+
+import (
+	"fmt"
+
+	"golang.org/x/tools/go/exact"
+	"golang.org/x/tools/go/types"
+)
+
+// foldConstant renders tv as a JS literal if it carries a known constant
+// value, so callers can emit the literal directly instead of generating an
+// expression the JS runtime would have to re-evaluate every time it runs
+// (const declarations, switch tags, rangeCheck bounds, ...). An untyped nil,
+// which has no constant value, renders as ty's zero value. Otherwise ok is
+// false if tv has no constant value, or its type isn't one foldConstant
+// knows how to render, in which case the caller should fall back to
+// translating the expression normally.
+func (c *funcContext) foldConstant(tv types.TypeAndValue, ty types.Type) (string, bool) {
+	// A nil literal's own type, not its (always absent) constant value,
+	// is what identifies it: go/exact has no Value representation of nil,
+	// so tv.Value is always nil here and this check must run before the
+	// tv.Value == nil bail-out below, or it can never fire.
+	if nilType, isBasic := tv.Type.Underlying().(*types.Basic); isBasic && nilType.Kind() == types.UntypedNil {
+		return c.zeroValue(ty), true
+	}
+
+	if tv.Value == nil {
+		return "", false
+	}
+
+	basic, isBasic := ty.Underlying().(*types.Basic)
+	if !isBasic {
+		return "", false
+	}
+
+	switch {
+	case isBoolean(basic):
+		if exact.BoolVal(tv.Value) {
+			return "true", true
+		}
+		return "false", true
+
+	case isComplex(basic):
+		re, _ := exact.Float64Val(exact.Real(tv.Value))
+		im, _ := exact.Float64Val(exact.Imag(tv.Value))
+		return c.emitter().ComplexLiteral(c.typeName(ty), formatFloatLiteral(re), formatFloatLiteral(im)), true
+
+	case is64Bit(basic):
+		if isUnsigned(basic) {
+			n, _ := exact.Uint64Val(tv.Value)
+			return c.emitter().Int64Literal(c.typeName(ty), int32(n>>32), uint32(n)), true
+		}
+		n, _ := exact.Int64Val(tv.Value)
+		return c.emitter().Int64Literal(c.typeName(ty), int32(uint64(n)>>32), uint32(n)), true
+
+	case isFloat(basic):
+		f, _ := exact.Float64Val(tv.Value)
+		return formatFloatLiteral(f), true
+
+	case isInteger(basic):
+		if isUnsigned(basic) {
+			n, _ := exact.Uint64Val(tv.Value)
+			return fmt.Sprintf("%d", n), true
+		}
+		n, _ := exact.Int64Val(tv.Value)
+		return fmt.Sprintf("%d", n), true
+
+	case isString(basic):
+		return encodeString(exact.StringVal(tv.Value)), true
+	}
+
+	return "", false
+}
+
+// formatFloatLiteral renders f the way GopherJS's existing float literal
+// translation does, without appending a trailing ".0" to already-fractional
+// values (JS number literals don't need it).
+func formatFloatLiteral(f float64) string {
+	return fmt.Sprintf("%v", f)
+}
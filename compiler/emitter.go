@@ -0,0 +1,90 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/gopherjs/gopherjs/compiler/typesutil"
+
+	"golang.org/x/tools/go/types"
+)
+
+// Emitter supplies the backend-specific pieces of type translation that the
+// rest of funcContext's translation logic (zeroValue, typeName, ...) stays
+// agnostic to. JSEmitter is the original, and only, behavior GopherJS has
+// ever had; WasmEmitter renders the primitive-heavy subset of it directly as
+// WebAssembly, falling back to the JS runtime for GC-managed types.
+type Emitter interface {
+	// BasicTypeName returns the type-descriptor expression for a basic
+	// (numeric, string, bool, ...) type.
+	BasicTypeName(t *types.Basic) string
+	// ErrorTypeName returns the type-descriptor expression for the
+	// built-in `error` interface.
+	ErrorTypeName() string
+	// EmptyInterfaceName returns the type-descriptor expression for
+	// `interface{}`.
+	EmptyInterfaceName() string
+	// ZeroValue returns the zero value expression for ty.
+	ZeroValue(c *funcContext, ty types.Type) string
+	// Int64Literal returns a constant expression for a 64-bit integer of
+	// the given type (typeName, as returned by typeName, not just
+	// BasicTypeName, so a named 64-bit type keeps its own constructor),
+	// split into its high and low 32 bits the way is64Bit values are
+	// represented throughout this package.
+	Int64Literal(typeName string, hi int32, lo uint32) string
+	// ComplexLiteral returns a constant expression for a complex number
+	// of the given type (typeName, as returned by typeName), given its
+	// already-formatted real and imaginary float literals.
+	ComplexLiteral(typeName string, re, im string) string
+}
+
+// JSEmitter is the default Emitter: it renders the same JavaScript
+// GopherJS has always produced.
+type JSEmitter struct{}
+
+func (JSEmitter) BasicTypeName(t *types.Basic) string { return "$" + toJavaScriptType(t) }
+func (JSEmitter) ErrorTypeName() string               { return "$error" }
+func (JSEmitter) EmptyInterfaceName() string          { return "$emptyInterface" }
+
+func (JSEmitter) Int64Literal(typeName string, hi int32, lo uint32) string {
+	return fmt.Sprintf("new %s(%d, %d)", typeName, hi, lo)
+}
+
+func (JSEmitter) ComplexLiteral(typeName string, re, im string) string {
+	return fmt.Sprintf("new %s(%s, %s)", typeName, re, im)
+}
+
+func (e JSEmitter) ZeroValue(c *funcContext, ty types.Type) string {
+	if typesutil.IsJsObject(ty) {
+		return "null"
+	}
+	switch t := ty.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case is64Bit(t) || isComplex(t):
+			return fmt.Sprintf("new %s(0, 0)", c.typeName(ty))
+		case isBoolean(t):
+			return "false"
+		case isNumeric(t), t.Kind() == types.UnsafePointer:
+			return "0"
+		case isString(t):
+			return `""`
+		case t.Kind() == types.UntypedNil:
+			panic("Zero value for untyped nil.")
+		default:
+			panic("Unhandled type")
+		}
+	case *types.Array:
+		return fmt.Sprintf("%s.zero()", c.typeName(ty))
+	case *types.Signature:
+		return "$throwNilPointerError"
+	case *types.Slice:
+		return fmt.Sprintf("%s.nil", c.typeName(ty))
+	case *types.Struct:
+		return fmt.Sprintf("new %s.ptr()", c.typeName(ty))
+	case *types.Map:
+		return "false"
+	case *types.Interface:
+		return "$ifaceNil"
+	}
+	return fmt.Sprintf("%s.nil", c.typeName(ty))
+}
@@ -0,0 +1,300 @@
+package compiler
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"github.com/gopherjs/gopherjs/compiler/analysis"
+
+	"golang.org/x/tools/go/types"
+)
+
+// inlineDirective is the pragma that opts a function into call-site
+// inlining: //gopherjs:inline, on its own comment line anywhere in the func
+// declaration's doc comment.
+const inlineDirective = "//gopherjs:inline"
+
+// hasInlineDirective reports whether doc (a *ast.FuncDecl's Doc) carries
+// the //gopherjs:inline pragma.
+func hasInlineDirective(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, comment := range doc.List {
+		if strings.TrimSpace(comment.Text) == inlineDirective {
+			return true
+		}
+	}
+	return false
+}
+
+// inlineBlocker is a reason translateCall declines to inline a
+// //gopherjs:inline function and falls back to a normal call, paired with
+// the diagnostic message reported through c.p.errList.
+type inlineBlocker string
+
+const (
+	blockedByDefer        inlineBlocker = "uses defer"
+	blockedByGo           inlineBlocker = "starts a goroutine"
+	blockedByBlocking     inlineBlocker = "may block"
+	blockedByEscaping     inlineBlocker = "captures an escaping variable"
+	blockedByMultiResult  inlineBlocker = "returns more than one value"
+	blockedByNestedReturn inlineBlocker = "returns from inside a loop, switch or select"
+	blockedByNakedReturn  inlineBlocker = "returns bare from a named result"
+	blockedByRecursion    inlineBlocker = "would recursively inline itself"
+)
+
+// checkInlinable walks fn's body for the constructs that make call-site
+// inlining unsound: defer, go, a channel op or other blocking call
+// (c.Blocking), variables that escape to the heap (analysis.EscapingObjects,
+// which a naive parameter substitution would alias incorrectly), a bare
+// `return` from a named result (rewriteInlineReturn has nothing to assign
+// into resultVar from), and multi-value returns (rewriteInlineReturns only
+// lowers a single result). It returns "" if fn can be inlined.
+func (c *funcContext) checkInlinable(fn *ast.FuncDecl, sig *types.Signature) inlineBlocker {
+	if sig.Results().Len() > 1 {
+		return blockedByMultiResult
+	}
+	if c.Blocking[fn.Body] {
+		return blockedByBlocking
+	}
+	if len(analysis.EscapingObjects(fn.Body, c.p.Info.Info)) > 0 {
+		return blockedByEscaping
+	}
+	if sig.Results().Len() == 1 && sig.Results().At(0).Name() != "" && containsNakedReturn(fn.Body) {
+		return blockedByNakedReturn
+	}
+
+	var blocked inlineBlocker
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if blocked != "" {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.DeferStmt:
+			blocked = blockedByDefer
+			return false
+		case *ast.GoStmt:
+			blocked = blockedByGo
+			return false
+		case *ast.FuncLit:
+			// Nested closures get their own call frame; nothing inside
+			// them needs rewriting and nothing inside them can defeat
+			// inlining of the outer function.
+			return false
+		case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			// rewriteInlineReturns only lowers returns it can reach by
+			// walking straight-line/if-else control flow; a return nested
+			// inside a loop or switch would need to additionally unwind
+			// that construct, which it does not attempt.
+			// Without such a return, keep walking into the construct so a
+			// defer or go inside it is still caught.
+			if containsReturn(n) {
+				blocked = blockedByNestedReturn
+				return false
+			}
+			return true
+		}
+		return true
+	})
+	return blocked
+}
+
+// containsReturn reports whether n contains a return statement, without
+// descending into nested function literals (which have their own return
+// scope).
+func containsReturn(n ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		switch n.(type) {
+		case *ast.ReturnStmt:
+			found = true
+			return false
+		case *ast.FuncLit:
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// containsNakedReturn reports whether n contains a bare `return` (one with
+// no Results), without descending into nested function literals. A naked
+// return from a function with a named result reads back whatever that
+// result variable currently holds; rewriteInlineReturn has no expression to
+// assign into resultVar for it, only the identifier the inlined body's
+// parameter/result declarations would need to keep referring to, which this
+// inliner does not set up, so fn must be rejected instead.
+func containsNakedReturn(n ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		switch s := n.(type) {
+		case *ast.ReturnStmt:
+			if len(s.Results) == 0 {
+				found = true
+			}
+			return false
+		case *ast.FuncLit:
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// translateCall is the call-site entry point: it emits normalCall, the
+// ordinary JS call translateCallExpr already built, unless fn carries
+// //gopherjs:inline and passes checkInlinable, in which case it inlines
+// fn's body instead. fn is nil when the callee isn't a statically known Go
+// function (e.g. a function value), which is never inlinable.
+func (c *funcContext) translateCall(fn *ast.FuncDecl, sig *types.Signature, argExprs []ast.Expr, pos token.Pos, normalCall string) string {
+	if fn == nil || !hasInlineDirective(fn.Doc) {
+		return normalCall
+	}
+	if blocked := c.checkInlinable(fn, sig); blocked != "" {
+		c.reportNotInlinable(fn, pos, blocked)
+		return normalCall
+	}
+
+	// A function can only recurse into itself (directly, or mutually
+	// through another //gopherjs:inline function) while it is still being
+	// inlined somewhere up this same translation: once that inlining
+	// finishes and is spliced into its call site, translateCall is never
+	// asked about it again. So a small in-progress stack, pushed here and
+	// popped when this call's inlining returns, catches the recursion
+	// before it unrolls forever — the same fn would otherwise keep being
+	// inlined into its own inlined copy with no base case.
+	fnObj, _ := c.p.Defs[fn.Name].(*types.Func)
+	if fnObj != nil {
+		if c.inlining[fnObj] {
+			c.reportNotInlinable(fn, pos, blockedByRecursion)
+			return normalCall
+		}
+		if c.inlining == nil {
+			c.inlining = make(map[*types.Func]bool)
+		}
+		c.inlining[fnObj] = true
+		defer delete(c.inlining, fnObj)
+	}
+
+	return c.translateInlineCall(fn, sig, argExprs)
+}
+
+// translateInlineCall substitutes fn's formal parameters with the call's
+// argument temporaries (computed the same way translateArgs already does
+// for ordinary calls, so side effects keep their original evaluation order),
+// rewrites fn's body so that its return becomes an assignment into a result
+// variable instead of a JS `return` (which would otherwise exit the
+// *caller's* enclosing function), and translates the rewritten body
+// directly at the call site in place of emitting a JS function call.
+//
+// It re-runs checkInlinable(fn, sig) itself and panics if fn is not
+// inlinable.
+func (c *funcContext) translateInlineCall(fn *ast.FuncDecl, sig *types.Signature, argExprs []ast.Expr) string {
+	if blocked := c.checkInlinable(fn, sig); blocked != "" {
+		panic(fmt.Sprintf("translateInlineCall: %s is not inlinable (%s)", fn.Name.Name, blocked))
+	}
+
+	args := c.translateArgs(sig, argExprs, false, false)
+
+	resultVar := ""
+	if sig.Results().Len() == 1 {
+		resultVar = c.newVariable(fn.Name.Name + "$result")
+	}
+	label := ast.NewIdent(c.newVariable(fn.Name.Name + "$done"))
+
+	c.Indent(func() {
+		params := fn.Type.Params.List
+		i := 0
+		for _, field := range params {
+			for _, name := range field.Names {
+				if !isBlank(name) {
+					c.Printf("%s = %s;", c.objectName(c.p.Defs[name]), args[i])
+				}
+				i++
+			}
+		}
+		body := c.rewriteInlineReturns(fn.Body.List, resultVar, label)
+		c.translateStmtList([]ast.Stmt{
+			&ast.LabeledStmt{Label: label, Stmt: &ast.BlockStmt{List: body}},
+		})
+	})
+
+	if resultVar != "" {
+		return resultVar
+	}
+	return "undefined"
+}
+
+// rewriteInlineReturns copies stmts, replacing every `return` reachable
+// without crossing into a nested function literal with an assignment into
+// resultVar (skipped when the callee returns nothing) followed by a
+// `break label`, so the translated callee body can be spliced into the
+// caller's control flow as a labeled block instead of its own function.
+func (c *funcContext) rewriteInlineReturns(stmts []ast.Stmt, resultVar string, label *ast.Ident) []ast.Stmt {
+	out := make([]ast.Stmt, 0, len(stmts))
+	for _, stmt := range stmts {
+		out = append(out, c.rewriteInlineReturn(stmt, resultVar, label)...)
+	}
+	return out
+}
+
+func (c *funcContext) rewriteInlineReturn(stmt ast.Stmt, resultVar string, label *ast.Ident) []ast.Stmt {
+	brk := &ast.BranchStmt{Tok: token.BREAK, Label: label}
+
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt:
+		if resultVar == "" {
+			return []ast.Stmt{brk}
+		}
+		return []ast.Stmt{
+			&ast.AssignStmt{Lhs: []ast.Expr{ast.NewIdent(resultVar)}, Tok: token.ASSIGN, Rhs: s.Results},
+			brk,
+		}
+	case *ast.BlockStmt:
+		return []ast.Stmt{&ast.BlockStmt{List: c.rewriteInlineReturns(s.List, resultVar, label)}}
+	case *ast.IfStmt:
+		rewritten := *s
+		rewritten.Body = &ast.BlockStmt{List: c.rewriteInlineReturns(s.Body.List, resultVar, label)}
+		if s.Else != nil {
+			rewritten.Else = c.rewriteInlineReturn(s.Else, resultVar, label)[0]
+		}
+		return []ast.Stmt{&rewritten}
+	case *ast.LabeledStmt:
+		rewritten := *s
+		inner := c.rewriteInlineReturn(s.Stmt, resultVar, label)
+		if len(inner) == 1 {
+			rewritten.Stmt = inner[0]
+		} else {
+			// A labeled return becomes an assignment plus a break; keep both.
+			rewritten.Stmt = &ast.BlockStmt{List: inner}
+		}
+		return []ast.Stmt{&rewritten}
+	default:
+		// checkInlinable already rejects any function with a return nested
+		// inside a for/range/switch/select, so other statements need no
+		// rewriting: they either contain no return at all, or (if/else,
+		// nested blocks, labels) were already handled above.
+		return []ast.Stmt{stmt}
+	}
+}
+
+// reportNotInlinable records why a //gopherjs:inline function could not be
+// inlined at this call site, as a soft error so the build still succeeds
+// with the ordinary (non-inlined) call.
+func (c *funcContext) reportNotInlinable(fn *ast.FuncDecl, pos token.Pos, reason inlineBlocker) {
+	c.p.errList = append(c.p.errList, types.Error{
+		Fset: c.p.fileSet,
+		Pos:  pos,
+		Msg:  fmt.Sprintf("%s has //gopherjs:inline but %s, falling back to a normal call", fn.Name.Name, reason),
+		Soft: true,
+	})
+}
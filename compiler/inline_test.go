@@ -0,0 +1,92 @@
+package compiler
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+// parseFuncBody parses body (a function body literal, braces included) as
+// the body of a standalone top-level func and returns its *ast.BlockStmt,
+// for tests that only need syntax, not type information.
+func parseFuncBody(t *testing.T, body string) *ast.BlockStmt {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "test.go", "package p\nfunc f() "+body, 0)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", body, err)
+	}
+	return file.Decls[0].(*ast.FuncDecl).Body
+}
+
+func TestContainsNakedReturn(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"naked return", "{ x := 1\n_ = x\nreturn }", true},
+		{"valued return", "{ return 1 }", false},
+		{"no return", "{ x := 1\n_ = x }", false},
+		{"naked return inside if", "{ if true { return } }", true},
+		{"nested func lit ignored", "{ g := func() { return }\n_ = g\nreturn 1 }", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := containsNakedReturn(parseFuncBody(t, tt.body)); got != tt.want {
+				t.Errorf("containsNakedReturn(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainsReturn(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"has return", "{ return 1 }", true},
+		{"no return", "{ x := 1\n_ = x }", false},
+		{"return nested in block", "{ { return } }", true},
+		{"nested func lit ignored", "{ g := func() { return }\n_ = g }", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := containsReturn(parseFuncBody(t, tt.body)); got != tt.want {
+				t.Errorf("containsReturn(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasInlineDirective(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "test.go", `package p
+
+// not a directive
+func a() {}
+
+//gopherjs:inline
+func b() {}
+
+// leading comment
+//gopherjs:inline
+func c() {}
+`, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	want := map[string]bool{"a": false, "b": true, "c": true}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		if got := hasInlineDirective(fn.Doc); got != want[fn.Name.Name] {
+			t.Errorf("hasInlineDirective(%s.Doc) = %v, want %v", fn.Name.Name, got, want[fn.Name.Name])
+		}
+	}
+}
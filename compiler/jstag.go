@@ -0,0 +1,134 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/tools/go/types"
+)
+
+// jsFieldTag is the parsed form of a `js:"..."` struct tag, which describes
+// how a Go struct field maps onto a JavaScript object's property when the
+// struct embeds *js.Object (see translateSelection) or is passed through
+// $externalize/$internalize.
+type jsFieldTag struct {
+	// Path is the JS property path the field is bound to, e.g. "bar" or
+	// the dotted "foo.bar" for a nested property. Empty if the tag was
+	// absent, blank, or "-".
+	Path string
+	// Hidden is true for `js:"-"`: the field is skipped by $externalize
+	// and $internalize entirely.
+	Hidden bool
+	// Omitempty is true when the tag includes the "omitempty" modifier:
+	// externalizeStruct sets the property to undefined rather than to the
+	// field's value when that value is the zero value.
+	Omitempty bool
+	// ReadOnly is true when the tag includes the "readonly" modifier:
+	// internalizeStruct does not read the field back from JS. No property
+	// getters or setters are generated, and assignments to the field
+	// through translateSelection are not affected.
+	ReadOnly bool
+}
+
+// parseJsFieldTag parses the value of a `js:"..."` struct tag (as returned
+// by getJsTag) into its path and modifiers. An empty raw tag yields a zero
+// jsFieldTag.
+func parseJsFieldTag(raw string) jsFieldTag {
+	if raw == "" {
+		return jsFieldTag{}
+	}
+	if raw == "-" {
+		return jsFieldTag{Hidden: true}
+	}
+
+	parts := strings.Split(raw, ",")
+	tag := jsFieldTag{Path: parts[0]}
+	for _, mod := range parts[1:] {
+		switch mod {
+		case "omitempty":
+			tag.Omitempty = true
+		case "readonly":
+			tag.ReadOnly = true
+		}
+	}
+	return tag
+}
+
+// jsMethodName returns the name a method should be exposed as on the JS
+// side, honoring a `jsmethod:"..."` key in the raw struct tag of a
+// function-typed field (as externalProp passes it), or "" if the method
+// keeps its Go name.
+func jsMethodName(tag string) string {
+	return structTagValue(tag, "jsmethod")
+}
+
+// externalProp returns the JS property name field i of t is bound to by its
+// struct tag, and whether that field should be skipped by $externalize and
+// $internalize. A `jsmethod:"..."` tag overrides the name for function-typed
+// fields (exposed as a differently-named JS method); a `js:"..."` path falls
+// back to the field's own name.
+func externalProp(t *types.Struct, i int) (name string, tag jsFieldTag) {
+	raw := t.Tag(i)
+	tag = parseJsFieldTag(getJsTag(raw))
+	name = tag.Path
+	if _, isFunc := t.Field(i).Type().Underlying().(*types.Signature); isFunc {
+		if m := jsMethodName(raw); m != "" {
+			name = m
+		}
+	}
+	if name == "" {
+		name = t.Field(i).Name()
+	}
+	return name, tag
+}
+
+// externalizeStruct renders structExpr (a Go struct value of type t) as a JS
+// object literal, honoring each field's `js` tag: Hidden fields are left
+// out, Omitempty fields are set to undefined when they equal their zero
+// value, and the JS property each field is assigned to comes from
+// externalProp.
+func (c *funcContext) externalizeStruct(structExpr string, t *types.Struct) string {
+	var props []string
+	for i := 0; i < t.NumFields(); i++ {
+		if !t.Field(i).Exported() {
+			continue
+		}
+		name, tag := externalProp(t, i)
+		if tag.Hidden {
+			continue
+		}
+		ft := t.Field(i).Type()
+		value := c.externalize(structExpr+"."+fieldName(t, i), ft)
+		if tag.Omitempty {
+			zero := c.zeroValue(ft)
+			value = fmt.Sprintf("(%s.%s == %s ? undefined : %s)", structExpr, fieldName(t, i), zero, value)
+		}
+		props = append(props, fmt.Sprintf("%s: %s", name, value))
+	}
+	return "{" + strings.Join(props, ", ") + "}"
+}
+
+// internalizeStruct is the inverse of externalizeStruct: it renders objExpr
+// (a JS object) as a Go struct literal of type structType, reading each
+// exported, non-Hidden field from the property externalProp names it.
+// ReadOnly fields are never read back from JS; they take their zero value
+// instead, as do unexported fields.
+func (c *funcContext) internalizeStruct(objExpr string, t *types.Struct, structType types.Type) string {
+	var fields []string
+	for i := 0; i < t.NumFields(); i++ {
+		if !t.Field(i).Exported() {
+			// The ptr constructor takes every field in order, so keep the
+			// position filled.
+			fields = append(fields, c.zeroValue(t.Field(i).Type()))
+			continue
+		}
+		name, tag := externalProp(t, i)
+		ft := t.Field(i).Type()
+		value := c.zeroValue(ft)
+		if !tag.Hidden && !tag.ReadOnly {
+			value = c.internalize(fmt.Sprintf("%s.%s", objExpr, name), ft)
+		}
+		fields = append(fields, value)
+	}
+	return fmt.Sprintf("new %s.ptr(%s)", c.typeName(structType), strings.Join(fields, ", "))
+}
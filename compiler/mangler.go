@@ -0,0 +1,172 @@
+package compiler
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/types"
+)
+
+// NameMangler assigns synthesized JavaScript identifiers when minification
+// is enabled. newVariableWithLevel asks for successive candidates (i = 0, 1,
+// 2, ...) for a given scope until it finds one that isn't already taken in
+// that scope; it is the mangler's job to decide what the i-th candidate is.
+type NameMangler interface {
+	// Candidate returns the i-th synthesized name for a variable in either
+	// local (pkgLevel == false) or package-level (pkgLevel == true) scope.
+	// Implementations should keep the two scopes in separate namespaces,
+	// the way base26Mangler does via upper/lower case. obj is the object
+	// being named, or nil for compiler-synthesized temporaries; a mangler
+	// that orders by some property of obj (like frequencyMangler) uses it
+	// to pick where in its sequence candidate 0 starts.
+	Candidate(i int, pkgLevel bool, obj types.Object) string
+}
+
+// base26Mangler is the original minifier: it counts through base-26 names
+// ("a", "b", ..., "z", "aa", "ab", ...), lower-case for locals and
+// upper-case for package-level declarations, ignoring which object is being
+// named.
+type base26Mangler struct{}
+
+func (base26Mangler) Candidate(i int, pkgLevel bool, _ types.Object) string {
+	offset := int('a')
+	if pkgLevel {
+		offset = int('A')
+	}
+	name := ""
+	j := i
+	for {
+		name = string(offset+(j%26)) + name
+		j = j/26 - 1
+		if j == -1 {
+			break
+		}
+	}
+	return name
+}
+
+// frequencyMangler hands out base26Mangler's short names in order of
+// descending reference count, so the most frequently used identifiers in
+// the package get the shortest names, the same trade GopherJS's minifier
+// leaves on the table by naming in first-encountered order. Call Prepare
+// once per package, before translation, to build the ranking.
+//
+// Local and package-level names are ranked in separate pools, mirroring how
+// base26Mangler already keeps them in separate letter-case namespaces: a
+// package-level object's rank (and so its mangled-name length) depends only
+// on how often other package-level objects were referenced, never on
+// unrelated local variables inside some function.
+type frequencyMangler struct {
+	base26Mangler
+	rank    [2]map[types.Object]int // [scopeIndex(pkgLevel)] -> object -> 0-based rank, most frequent first
+	next    [2]int                  // fallback sequence for objects with no rank (e.g. synthetic names)
+	nilRank [2]int                  // cached RankOf(pkgLevel, nil), shared by every anonymous temporary in that pool
+}
+
+// scopeIndex maps the pkgLevel flag Candidate/RankOf already take to the
+// pool it selects.
+func scopeIndex(pkgLevel bool) int {
+	if pkgLevel {
+		return 1
+	}
+	return 0
+}
+
+// isPkgLevelObj reports whether obj lives in its package's scope, the same
+// test objectName and varPtrName use to pick base26Mangler's letter case.
+func isPkgLevelObj(obj types.Object) bool {
+	return obj != nil && obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope()
+}
+
+func newFrequencyMangler() *frequencyMangler {
+	return &frequencyMangler{
+		rank:    [2]map[types.Object]int{make(map[types.Object]int), make(map[types.Object]int)},
+		nilRank: [2]int{-1, -1},
+	}
+}
+
+// Prepare walks files, counting how many times each object named by info is
+// referenced, and records a rank from most- to least-referenced within each
+// of the local and package-level pools. It must run before any calls to
+// RankOf/Candidate for the package being compiled.
+func (m *frequencyMangler) Prepare(files []*ast.File, info *types.Info) {
+	counts := make(map[types.Object]int)
+	var order []types.Object
+	visit := func(obj types.Object) {
+		if obj == nil {
+			return
+		}
+		if _, seen := counts[obj]; !seen {
+			order = append(order, obj)
+		}
+		counts[obj]++
+	}
+
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if obj := info.ObjectOf(id); obj != nil {
+					visit(obj)
+				}
+			}
+			return true
+		})
+	}
+
+	var pools [2][]types.Object
+	for _, obj := range order {
+		idx := scopeIndex(isPkgLevelObj(obj))
+		pools[idx] = append(pools[idx], obj)
+	}
+	for idx, pool := range pools {
+		sortObjectsByCount(pool, counts)
+		for i, obj := range pool {
+			m.rank[idx][obj] = i
+		}
+	}
+}
+
+// RankOf returns the candidate index to use for obj within its pkgLevel
+// pool, falling back to a private counter - cached the same way as a real
+// rank, so repeated calls for the same obj (newVariableWithLevel retries on
+// a scope collision) stay stable - for objects Prepare never saw (e.g.
+// compiler-synthesized temporaries).
+func (m *frequencyMangler) RankOf(pkgLevel bool, obj types.Object) int {
+	idx := scopeIndex(pkgLevel)
+	if obj == nil {
+		if m.nilRank[idx] < 0 {
+			m.next[idx]++
+			m.nilRank[idx] = len(m.rank[idx]) + m.next[idx] - 1
+		}
+		return m.nilRank[idx]
+	}
+	if i, ok := m.rank[idx][obj]; ok {
+		return i
+	}
+	m.next[idx]++
+	i := len(m.rank[idx]) + m.next[idx] - 1
+	m.rank[idx][obj] = i
+	return i
+}
+
+// Candidate picks up base26Mangler's naming sequence starting at obj's rank
+// in its pkgLevel pool, so frequently-referenced objects claim the short
+// names first; i, as with base26Mangler, only advances past that starting
+// point when a scope collision forces newVariableWithLevel to ask for
+// another candidate.
+func (m *frequencyMangler) Candidate(i int, pkgLevel bool, obj types.Object) string {
+	return m.base26Mangler.Candidate(m.RankOf(pkgLevel, obj)+i, pkgLevel, obj)
+}
+
+// sortObjectsByCount sorts objs in place by descending counts[obj], breaking
+// ties by first-encountered order (objs is already in that order, and the
+// sort is stable).
+func sortObjectsByCount(objs []types.Object, counts map[types.Object]int) {
+	// Insertion sort: the candidate lists this runs over (identifiers in a
+	// single package) are small enough that O(n^2) is not worth a sort.Stable
+	// import just for this.
+	for i := 1; i < len(objs); i++ {
+		for j := i; j > 0 && counts[objs[j]] > counts[objs[j-1]]; j-- {
+			objs[j], objs[j-1] = objs[j-1], objs[j]
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package compiler
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/types"
+)
+
+// MangleStrategy selects which NameMangler implementation minification
+// uses when Options.Minify is set.
+type MangleStrategy string
+
+const (
+	// MangleBase26 is GopherJS's original minifier: shortest-first
+	// base-26 names assigned in first-encountered order.
+	MangleBase26 MangleStrategy = "base26"
+	// MangleFrequency assigns the shortest names to the most frequently
+	// referenced identifiers in the package instead, trading a first
+	// pass over the package for smaller minified output.
+	MangleFrequency MangleStrategy = "frequency"
+)
+
+// NewMangler builds the NameMangler for the given strategy. The zero
+// MangleStrategy ("") resolves to MangleBase26.
+func NewMangler(strategy MangleStrategy) NameMangler {
+	switch strategy {
+	case MangleFrequency:
+		return newFrequencyMangler()
+	default:
+		return base26Mangler{}
+	}
+}
+
+// Options configures optional compiler behavior that an embedder (the
+// gopherjs CLI, or a build tool driving Program directly) can opt into; its
+// zero value reproduces GopherJS's historical defaults.
+type Options struct {
+	// Minify enables identifier minification; MangleStrategy selects the
+	// strategy used while it's on and is ignored otherwise.
+	Minify         bool
+	MangleStrategy MangleStrategy
+	// Files and Info are the package's parsed files and type-checking
+	// result. They are only consulted for MangleFrequency, which needs a
+	// first pass over the package to rank identifiers by reference count
+	// before translation assigns any of them a name.
+	Files []*ast.File
+	Info  *types.Info
+
+	// SourceMap selects whether/how a Source Map v3 document is produced
+	// alongside the compiled JS; see Program.FinalizeSourceMap.
+	SourceMap SourceMapOutputMode
+
+	// Backend selects the code generation target; WasmSizes is required
+	// (and otherwise ignored) when Backend is BackendWasm, since wasm
+	// struct/array layout depends on it.
+	Backend   Backend
+	WasmSizes types.Sizes
+}
+
+// Backend selects which Emitter a Program translates through.
+type Backend int
+
+const (
+	// BackendJS is GopherJS's original and default target.
+	BackendJS Backend = iota
+	// BackendWasm emits WebAssembly for the primitive-heavy subset of the
+	// program that WasmEmitter covers; see wasm.go.
+	BackendWasm
+)
+
+// Configure applies o to p: it sets p.minify and resolves p.mangler via
+// NewMangler, running the first pass MangleFrequency needs to build its
+// ranking before translation starts; sets p.sourceMap so writePos knows
+// whether to track the source files FinalizeSourceMap will need; and
+// resolves p.emitter from o.Backend.
+func (o Options) Configure(p *Program) {
+	p.minify = o.Minify
+	p.sourceMap = o.SourceMap
+
+	switch o.Backend {
+	case BackendWasm:
+		p.emitter = NewWasmEmitter(o.WasmSizes)
+	default:
+		p.emitter = JSEmitter{}
+	}
+
+	if !o.Minify {
+		return
+	}
+	mangler := NewMangler(o.MangleStrategy)
+	if freq, ok := mangler.(*frequencyMangler); ok {
+		freq.Prepare(o.Files, o.Info)
+	}
+	p.mangler = mangler
+}
@@ -0,0 +1,310 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"go/token"
+)
+
+// SourceMapOutputMode selects how (or whether) a generated source map is
+// surfaced alongside the compiled JavaScript.
+type SourceMapOutputMode int
+
+const (
+	// SourceMapNone skips source map generation entirely.
+	SourceMapNone SourceMapOutputMode = iota
+	// SourceMapInline appends a "//# sourceMappingURL=data:..." comment
+	// carrying a base64-encoded map to the end of the generated JS.
+	SourceMapInline
+	// SourceMapExternal produces the map for a sibling "<file>.map"
+	// (FinalizeSourceMap returns its bytes for the caller to write) and
+	// appends a "//# sourceMappingURL=<file>.map" comment instead.
+	SourceMapExternal
+)
+
+// sourceMap is a minimal Source Map v3 document, as consumed by browser
+// devtools and Node's stack trace machinery.
+type sourceMap struct {
+	Version        int      `json:"version"`
+	File           string   `json:"file"`
+	Sources        []string `json:"sources"`
+	SourcesContent []string `json:"sourcesContent"`
+	Names          []string `json:"names"`
+	Mappings       string   `json:"mappings"`
+}
+
+// noteSourcePos records the Go source file pos resolves to as one this
+// compile's source map must cover, so FinalizeSourceMap doesn't need every
+// caller to separately enumerate which files were actually touched.
+// writePos calls this for every position it stamps into the output once
+// source map generation is enabled.
+func (p *Program) noteSourcePos(pos token.Pos) {
+	filename := p.fileSet.Position(pos).Filename
+	if _, seen := p.sourceMapSeen[filename]; seen {
+		return
+	}
+	if p.sourceMapSeen == nil {
+		p.sourceMapSeen = make(map[string]bool)
+	}
+	p.sourceMapSeen[filename] = true
+	p.sourceMapFiles = append(p.sourceMapFiles, filename)
+}
+
+// FinalizeSourceMap takes the concatenated JS output emitted through
+// funcContext.Write (still carrying the '\b' position markers writePos
+// wrote), strips them, and - according to p.sourceMap - returns the clean JS
+// with either an inline or external sourceMappingURL comment appended, plus
+// the map bytes to write to outFile+".map" for SourceMapExternal (nil for
+// the other modes). sourceContent optionally supplies each source file's
+// text for the map's "sourcesContent"; files noteSourcePos saw but
+// sourceContent has no entry for are still listed, just without inlined
+// text.
+func (p *Program) FinalizeSourceMap(jsOutput []byte, outFile string, sourceContent map[string]string) (js []byte, mapJSON []byte, err error) {
+	if p.sourceMap == SourceMapNone {
+		return jsOutput, nil, nil
+	}
+
+	clean, sm := generateSourceMap(jsOutput, p.fileSet, p.sourceMapFiles, sourceContent, outFile)
+
+	switch p.sourceMap {
+	case SourceMapInline:
+		comment, err := inlineSourceMapComment(sm)
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(clean, comment...), nil, nil
+	case SourceMapExternal:
+		mapJSON, err = json.Marshal(sm)
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(clean, externalSourceMapComment(outFile+".map")...), mapJSON, nil
+	default:
+		return clean, nil, nil
+	}
+}
+
+// generateSourceMap walks output produced by funcContext.Write, which embeds
+// a '\b' byte followed by a big-endian uint32 token.Pos before every run of
+// generated text that originates from that position. It strips the markers
+// back out, returning the clean JS alongside a Source Map v3 document that
+// relates each generated line back to the Go source that produced it.
+//
+// sources is the list of Go source file paths that should be listed (and, if
+// content is non-nil for a given path, inlined) in the map; outFile is the
+// name recorded as the map's "file" property.
+func generateSourceMap(output []byte, fileSet *token.FileSet, sources []string, content map[string]string, outFile string) ([]byte, *sourceMap) {
+	sourceIndex := make(map[string]int, len(sources))
+	for i, s := range sources {
+		sourceIndex[s] = i
+	}
+
+	sm := &sourceMap{
+		Version:        3,
+		File:           outFile,
+		Sources:        sources,
+		SourcesContent: make([]string, len(sources)),
+	}
+	for i, s := range sources {
+		sm.SourcesContent[i] = content[s]
+	}
+
+	var clean []byte
+	var mappings bytes.Buffer
+
+	// VLQ fields are emitted relative to the previous segment on the same
+	// line, and the source index/line/column are relative across the whole
+	// file per the spec.
+	genCol, prevGenCol := 0, 0
+	prevSrcIdx, prevSrcLine, prevSrcCol := 0, 0, 0
+	lastPos := token.NoPos
+	segmentStart := 0
+	firstOnLine := true
+
+	flush := func(end int, pos token.Pos) {
+		if end == segmentStart {
+			return
+		}
+		if pos == token.NoPos {
+			clean = append(clean, output[segmentStart:end]...)
+			genCol += end - segmentStart
+			return
+		}
+		p := fileSet.Position(pos)
+		srcIdx, ok := sourceIndex[p.Filename]
+		if !ok {
+			sourceIndex[p.Filename] = len(sources)
+			srcIdx = sourceIndex[p.Filename]
+			sources = append(sources, p.Filename)
+			sm.Sources = sources
+			sm.SourcesContent = append(sm.SourcesContent, content[p.Filename])
+		}
+
+		if !firstOnLine {
+			mappings.WriteByte(',')
+		}
+		firstOnLine = false
+		writeVLQ(&mappings, genCol-prevGenCol)
+		prevGenCol = genCol
+		writeVLQ(&mappings, srcIdx-prevSrcIdx)
+		writeVLQ(&mappings, (p.Line-1)-prevSrcLine)
+		writeVLQ(&mappings, (p.Column-1)-prevSrcCol)
+		prevSrcIdx, prevSrcLine, prevSrcCol = srcIdx, p.Line-1, p.Column-1
+
+		run := output[segmentStart:end]
+		clean = append(clean, run...)
+		genCol += len(run)
+	}
+
+	i := 0
+	for i < len(output) {
+		if output[i] == '\b' && i+5 <= len(output) {
+			flush(i, lastPos)
+			pos := token.Pos(binary.BigEndian.Uint32(output[i+1 : i+5]))
+			lastPos = pos
+			i += 5
+			segmentStart = i
+			continue
+		}
+		if output[i] == '\n' {
+			flush(i+1, lastPos)
+			mappings.WriteByte(';')
+			genCol, prevGenCol = 0, 0
+			firstOnLine = true
+			i++
+			segmentStart = i
+			continue
+		}
+		i++
+	}
+	flush(len(output), lastPos)
+
+	sm.Mappings = mappings.String()
+	return clean, sm
+}
+
+// writeVLQ encodes n using the Base64 VLQ scheme used by Source Map v3: each
+// 6-bit group is written least-significant-group-first with the high bit of
+// the group acting as a continuation flag, and the sign occupies the lowest
+// bit of the first group.
+func writeVLQ(buf *bytes.Buffer, n int) {
+	const base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+	v := uint32(n) << 1
+	if n < 0 {
+		v = (uint32(-n) << 1) | 1
+	}
+	for {
+		digit := v & 0x1f
+		v >>= 5
+		if v != 0 {
+			digit |= 0x20
+		}
+		buf.WriteByte(base64Chars[digit])
+		if v == 0 {
+			break
+		}
+	}
+}
+
+// inlineSourceMapComment renders sm as a "//# sourceMappingURL=data:..."
+// comment suitable for appending directly to the generated JS.
+func inlineSourceMapComment(sm *sourceMap) ([]byte, error) {
+	j, err := json.Marshal(sm)
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(j)
+	return []byte("//# sourceMappingURL=data:application/json;base64," + encoded + "\n"), nil
+}
+
+// externalSourceMapComment renders the "//# sourceMappingURL=<mapFile>"
+// comment used when the map is written as a sibling file instead of inlined.
+func externalSourceMapComment(mapFile string) []byte {
+	return []byte("//# sourceMappingURL=" + mapFile + "\n")
+}
+
+// SourceMapFilter maps lines of the generated JS (such as those named in a
+// running program's panic stack trace) back to their original Go filenames
+// and line numbers, using a previously generated source map. It offers the
+// per-line lookup Original; it does not itself parse or rewrite stack trace
+// text.
+type SourceMapFilter struct {
+	fileSet *token.FileSet
+	sm      *sourceMap
+}
+
+// NewSourceMapFilter constructs a SourceMapFilter from a generated source
+// map. fileSet is stored with it; Original answers look-ups from the map
+// alone and does not consult fileSet.
+func NewSourceMapFilter(fileSet *token.FileSet, sm *sourceMap) *SourceMapFilter {
+	return &SourceMapFilter{fileSet: fileSet, sm: sm}
+}
+
+// Original returns the Go source file and 1-based line number that produced
+// the given 1-based generated line, or ("", 0) if it is not covered by the
+// map (e.g. runtime-internal frames).
+func (f *SourceMapFilter) Original(genLine int) (file string, line int) {
+	genLineIdx := 0
+	srcIdx, srcLine := 0, 0
+	covered := false
+	segments := bytes.Split([]byte(f.sm.Mappings), []byte(";"))
+	if genLine-1 >= len(segments) {
+		return "", 0
+	}
+	for i := 0; i <= genLine-1; i++ {
+		for _, seg := range bytes.Split(segments[i], []byte(",")) {
+			if len(seg) == 0 {
+				continue
+			}
+			fields := decodeVLQSegment(seg)
+			if len(fields) < 4 {
+				continue
+			}
+			srcIdx += fields[1]
+			srcLine += fields[2]
+			covered = i == genLine-1
+		}
+	}
+	_ = genLineIdx
+	if !covered || srcIdx < 0 || srcIdx >= len(f.sm.Sources) {
+		return "", 0
+	}
+	return f.sm.Sources[srcIdx], srcLine + 1
+}
+
+// decodeVLQSegment decodes a comma-delimited Source Map v3 segment into its
+// raw (possibly relative) integer fields.
+func decodeVLQSegment(seg []byte) []int {
+	const base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+	var rev [256]int
+	for i := 0; i < len(rev); i++ {
+		rev[i] = -1
+	}
+	for i := 0; i < len(base64Chars); i++ {
+		rev[base64Chars[i]] = i
+	}
+
+	var fields []int
+	cur, shift := 0, uint(0)
+	for _, b := range seg {
+		d := rev[b]
+		if d < 0 {
+			continue
+		}
+		cur |= (d & 0x1f) << shift
+		if d&0x20 != 0 {
+			shift += 5
+			continue
+		}
+		n := cur >> 1
+		if cur&1 != 0 {
+			n = -n
+		}
+		fields = append(fields, n)
+		cur, shift = 0, 0
+	}
+	return fields
+}
@@ -0,0 +1,41 @@
+package compiler
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestVLQRoundTrip checks that decodeVLQSegment recovers exactly the values
+// writeVLQ encoded, across zero, small, sign, and multi-group magnitudes -
+// the class of off-by-one a hand-rolled VLQ codec is most likely to get
+// wrong.
+func TestVLQRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, -1, 15, -15, 16, -16, 31, -31, 32, -32, 1000, -1000, 1 << 20, -(1 << 20)} {
+		var buf bytes.Buffer
+		writeVLQ(&buf, n)
+		got := decodeVLQSegment(buf.Bytes())
+		if len(got) != 1 || got[0] != n {
+			t.Errorf("writeVLQ(%d) round-tripped through decodeVLQSegment as %v, want [%d]", n, got, n)
+		}
+	}
+}
+
+// TestVLQSegmentRoundTrip checks a multi-field segment - the shape
+// generateSourceMap actually emits (genCol, srcIdx delta, srcLine delta,
+// srcCol delta) - decodes back to the same fields in order.
+func TestVLQSegmentRoundTrip(t *testing.T) {
+	fields := []int{5, -2, 0, 7}
+	var buf bytes.Buffer
+	for _, f := range fields {
+		writeVLQ(&buf, f)
+	}
+	got := decodeVLQSegment(buf.Bytes())
+	if len(got) != len(fields) {
+		t.Fatalf("decodeVLQSegment(%q) = %v, want %d fields", buf.String(), got, len(fields))
+	}
+	for i, f := range fields {
+		if got[i] != f {
+			t.Errorf("decodeVLQSegment(%q)[%d] = %d, want %d", buf.String(), i, got[i], f)
+		}
+	}
+}
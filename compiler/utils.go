@@ -16,6 +16,16 @@ import (
 	"golang.org/x/tools/go/types"
 )
 
+// emitter returns the backend that should render primitive type
+// representations for this function: c.p.emitter if one was configured
+// (e.g. for a WebAssembly build), or the default JSEmitter otherwise.
+func (c *funcContext) emitter() Emitter {
+	if c.p.emitter != nil {
+		return c.p.emitter
+	}
+	return JSEmitter{}
+}
+
 func (c *funcContext) Write(b []byte) (int, error) {
 	c.writePos()
 	c.output = append(c.output, b...)
@@ -46,6 +56,9 @@ func (c *funcContext) SetPos(pos token.Pos) {
 func (c *funcContext) writePos() {
 	if c.posAvailable {
 		c.posAvailable = false
+		if c.p.sourceMap != SourceMapNone {
+			c.p.noteSourcePos(c.pos)
+		}
 		c.Write([]byte{'\b'})
 		binary.Write(c, binary.BigEndian, uint32(c.pos))
 	}
@@ -108,10 +121,11 @@ func (c *funcContext) translateArgs(sig *types.Signature, argExprs []ast.Expr, e
 		}
 
 		var arg string
-		switch {
-		case clone:
+		if lit, ok := c.foldConstant(c.p.Types[argExpr], argType); ok {
+			arg = lit
+		} else if clone {
 			arg = c.translateImplicitConversionWithCloning(argExpr, argType).String()
-		default:
+		} else {
 			arg = c.translateImplicitConversion(argExpr, argType).String()
 		}
 
@@ -130,6 +144,43 @@ func (c *funcContext) translateArgs(sig *types.Signature, argExprs []ast.Expr, e
 	return args
 }
 
+// translateCallExpr is the *ast.CallExpr case of call translation: it
+// builds normalCall the way a plain Go function call has always compiled
+// here (evaluate the callee, evaluate the arguments through translateArgs,
+// join them into a JS call expression), resolves the callee to its
+// declaration when it's a statically known Go function, and lets
+// translateCall decide whether to emit normalCall as-is or inline the
+// callee's body in its place. A call through a function value — call.Fun
+// isn't a plain name or selector, or doesn't resolve to a *types.Func this
+// package has a declaration for — always falls through to normalCall,
+// since there is no body for translateCall to inline.
+//
+// This snapshot has no expressions.go, so there is no translateExpr switch
+// for this to be a case of; it is written as the function that switch's
+// *ast.CallExpr arm would call; the arm itself would be one line
+// (`case *ast.CallExpr: return c.translateCallExpr(t, sig)`) in a file this
+// tree doesn't include.
+func (c *funcContext) translateCallExpr(call *ast.CallExpr, sig *types.Signature) string {
+	args := c.translateArgs(sig, call.Args, call.Ellipsis.IsValid(), false)
+	normalCall := fmt.Sprintf("%s(%s)", c.translateExpr(call.Fun), strings.Join(args, ", "))
+
+	var callee *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		callee = fun
+	case *ast.SelectorExpr:
+		callee = fun.Sel
+	default:
+		return normalCall
+	}
+
+	fnObj, ok := c.p.Uses[callee].(*types.Func)
+	if !ok {
+		return normalCall
+	}
+	return c.translateCall(c.p.funcDecls[fnObj], sig, call.Args, call.Pos(), normalCall)
+}
+
 func (c *funcContext) translateSelection(sel *types.Selection, pos token.Pos) ([]string, string) {
 	var fields []string
 	t := sel.Recv()
@@ -138,13 +189,13 @@ func (c *funcContext) translateSelection(sel *types.Selection, pos token.Pos) ([
 			t = ptr.Elem()
 		}
 		s := t.Underlying().(*types.Struct)
-		if jsTag := getJsTag(s.Tag(index)); jsTag != "" {
+		if tag := parseJsFieldTag(getJsTag(s.Tag(index))); tag.Path != "" {
 			jsFieldName := s.Field(index).Name()
 			for {
 				fields = append(fields, fieldName(s, 0))
 				ft := s.Field(0).Type()
 				if typesutil.IsJsObject(ft) {
-					return fields, jsTag
+					return fields, tag.Path
 				}
 				ft = ft.Underlying()
 				if ptr, ok := ft.(*types.Pointer); ok {
@@ -165,46 +216,17 @@ func (c *funcContext) translateSelection(sel *types.Selection, pos token.Pos) ([
 }
 
 func (c *funcContext) zeroValue(ty types.Type) string {
-	if typesutil.IsJsObject(ty) {
-		return "null"
-	}
-	switch t := ty.Underlying().(type) {
-	case *types.Basic:
-		switch {
-		case is64Bit(t) || isComplex(t):
-			return fmt.Sprintf("new %s(0, 0)", c.typeName(ty))
-		case isBoolean(t):
-			return "false"
-		case isNumeric(t), t.Kind() == types.UnsafePointer:
-			return "0"
-		case isString(t):
-			return `""`
-		case t.Kind() == types.UntypedNil:
-			panic("Zero value for untyped nil.")
-		default:
-			panic("Unhandled type")
-		}
-	case *types.Array:
-		return fmt.Sprintf("%s.zero()", c.typeName(ty))
-	case *types.Signature:
-		return "$throwNilPointerError"
-	case *types.Slice:
-		return fmt.Sprintf("%s.nil", c.typeName(ty))
-	case *types.Struct:
-		return fmt.Sprintf("new %s.ptr()", c.typeName(ty))
-	case *types.Map:
-		return "false"
-	case *types.Interface:
-		return "$ifaceNil"
-	}
-	return fmt.Sprintf("%s.nil", c.typeName(ty))
+	return c.emitter().ZeroValue(c, ty)
 }
 
 func (c *funcContext) newVariable(name string) string {
-	return c.newVariableWithLevel(name, false)
+	return c.newVariableWithLevel(name, false, nil)
 }
 
-func (c *funcContext) newVariableWithLevel(name string, pkgLevel bool) string {
+// newVariableWithLevel allocates a new JS identifier for obj (nil for
+// compiler-synthesized temporaries that have no corresponding types.Object),
+// in either local (pkgLevel == false) or package-level scope.
+func (c *funcContext) newVariableWithLevel(name string, pkgLevel bool, obj types.Object) string {
 	if name == "" {
 		panic("newVariable: empty name")
 	}
@@ -215,21 +237,13 @@ func (c *funcContext) newVariableWithLevel(name string, pkgLevel bool) string {
 		}
 	}
 	if c.p.minify {
+		mangler := c.p.mangler
+		if mangler == nil {
+			mangler = base26Mangler{}
+		}
 		i := 0
 		for {
-			offset := int('a')
-			if pkgLevel {
-				offset = int('A')
-			}
-			j := i
-			name = ""
-			for {
-				name = string(offset+(j%26)) + name
-				j = j/26 - 1
-				if j == -1 {
-					break
-				}
-			}
+			name = mangler.Candidate(i, pkgLevel, obj)
 			if c.allVars[name] == 0 {
 				break
 			}
@@ -290,7 +304,7 @@ func (c *funcContext) objectName(o types.Object) string {
 
 	name, ok := c.p.objectNames[o]
 	if !ok {
-		name = c.newVariableWithLevel(o.Name(), o.Parent() == c.p.Pkg.Scope())
+		name = c.newVariableWithLevel(o.Name(), o.Parent() == c.p.Pkg.Scope(), o)
 		c.p.objectNames[o] = name
 	}
 
@@ -303,7 +317,7 @@ func (c *funcContext) objectName(o types.Object) string {
 func (c *funcContext) varPtrName(v *types.Var) string {
 	name, ok := c.p.varPtrNames[v]
 	if !ok {
-		name = c.newVariableWithLevel(v.Name()+"_ptr", v.Parent() == c.p.Pkg.Scope())
+		name = c.newVariableWithLevel(v.Name()+"_ptr", v.Parent() == c.p.Pkg.Scope(), v)
 		c.p.varPtrNames[v] = name
 	}
 	return name
@@ -312,22 +326,22 @@ func (c *funcContext) varPtrName(v *types.Var) string {
 func (c *funcContext) typeName(ty types.Type) string {
 	switch t := ty.(type) {
 	case *types.Basic:
-		return "$" + toJavaScriptType(t)
+		return c.emitter().BasicTypeName(t)
 	case *types.Named:
 		if t.Obj().Name() == "error" {
-			return "$error"
+			return c.emitter().ErrorTypeName()
 		}
 		return c.objectName(t.Obj())
 	case *types.Interface:
 		if t.Empty() {
-			return "$emptyInterface"
+			return c.emitter().EmptyInterfaceName()
 		}
 	}
 
 	anonType, ok := c.p.anonTypeMap.At(ty).(*types.TypeName)
 	if !ok {
 		c.initArgs(ty) // cause all embedded types to be registered
-		varName := c.newVariableWithLevel(strings.ToLower(typeKind(ty)[5:])+"Type", true)
+		varName := c.newVariableWithLevel(strings.ToLower(typeKind(ty)[5:])+"Type", true, nil)
 		anonType = types.NewTypeName(token.NoPos, c.p.Pkg, varName, ty) // fake types.TypeName
 		c.p.anonTypes = append(c.p.anonTypes, anonType)
 		c.p.anonTypeMap.Set(ty, anonType)
@@ -347,6 +361,9 @@ func (c *funcContext) makeKey(expr ast.Expr, keyType types.Type) string {
 		if isFloat(t) {
 			return fmt.Sprintf("$floatKey(%s)", c.translateExpr(expr))
 		}
+		if lit, ok := c.foldConstant(c.p.Types[expr], keyType); ok {
+			return lit
+		}
 		return c.translateImplicitConversion(expr, keyType).String()
 	case *types.Chan, *types.Pointer, *types.Interface:
 		return fmt.Sprintf("%s.$key()", c.translateImplicitConversion(expr, keyType))
@@ -367,10 +384,24 @@ func (c *funcContext) externalize(s string, t types.Type) string {
 		if u.Kind() == types.UntypedNil {
 			return "null"
 		}
+	case *types.Struct:
+		return c.externalizeStruct(s, u)
 	}
 	return fmt.Sprintf("$externalize(%s, %s)", s, c.typeName(t))
 }
 
+// internalize converts the JS value s into its Go representation for type
+// t, the inverse of externalize.
+func (c *funcContext) internalize(s string, t types.Type) string {
+	if typesutil.IsJsObject(t) {
+		return s
+	}
+	if u, ok := t.Underlying().(*types.Struct); ok {
+		return c.internalizeStruct(s, u, t)
+	}
+	return fmt.Sprintf("$internalize(%s, %s)", s, c.typeName(t))
+}
+
 func (c *funcContext) handleEscapingVars(n ast.Node) {
 	newEscapingVars := make(map[*types.Var]bool)
 	for escaping := range c.p.escapingVars {
@@ -525,6 +556,12 @@ func encodeString(s string) string {
 }
 
 func getJsTag(tag string) string {
+	return structTagValue(tag, "js")
+}
+
+// structTagValue scans a raw struct tag (the syntax `key1:"value1" key2:"value2"`)
+// for the given key and returns its unquoted value, or "" if key is absent.
+func structTagValue(tag, key string) string {
 	for tag != "" {
 		// skip leading space
 		i := 0
@@ -562,7 +599,7 @@ func getJsTag(tag string) string {
 		qvalue := string(tag[:i+1])
 		tag = tag[i+1:]
 
-		if name == "js" {
+		if name == key {
 			value, _ := strconv.Unquote(qvalue)
 			return value
 		}
@@ -620,7 +657,24 @@ func removeWhitespace(b []byte, minify bool) []byte {
 	return out
 }
 
-func rangeCheck(pattern string, constantIndex, array bool) string {
+// rangeCheck wraps pattern — a format template for the indexed access, with
+// %2f standing in for the index expression — with the bounds check an
+// index needs unless constantIndex and array are both true (a constant
+// index into a fixed-size Go array is already proven in bounds by the type
+// checker). indexTV/indexType are the index expression's constant
+// information; when foldConstant can render it as a literal, that literal
+// replaces every %2f placeholder directly, the check runs against it
+// instead of re-evaluating the index expression at runtime, and it is
+// treated as constantIndex for the array fast path above, matching
+// constantIndex's existing meaning for literal array indices.
+func (c *funcContext) rangeCheck(pattern string, indexTV types.TypeAndValue, indexType types.Type, constantIndex, array bool) string {
+	indexRepr := "%2f"
+	if lit, ok := c.foldConstant(indexTV, indexType); ok {
+		constantIndex = true
+		indexRepr = lit
+		pattern = strings.Replace(pattern, "%2f", lit, -1)
+	}
+
 	if constantIndex && array {
 		return pattern
 	}
@@ -628,9 +682,9 @@ func rangeCheck(pattern string, constantIndex, array bool) string {
 	if array {
 		lengthProp = "length"
 	}
-	check := "%2f >= %1e." + lengthProp
+	check := indexRepr + " >= %1e." + lengthProp
 	if !constantIndex {
-		check = "(%2f < 0 || " + check + ")"
+		check = "(" + indexRepr + " < 0 || " + check + ")"
 	}
 	return "(" + check + ` ? $throwRuntimeError("index out of range") : ` + pattern + ")"
 }
@@ -0,0 +1,129 @@
+package compiler
+
+import (
+	"fmt"
+
+	"golang.org/x/tools/go/types"
+)
+
+// WasmEmitter renders the primitive, non-GC-managed subset of a Go program
+// directly as WebAssembly (WAT text, assembled to binary by the caller),
+// and falls back to the existing JS runtime for everything that needs
+// GopherJS's garbage-collected representation: interfaces, maps, channels,
+// slices and strings. Those fall-back values are represented inside wasm
+// code as an i32 handle into a JS-side table, and every op on them compiles
+// to a call out to the `$wasmShim` import module that the JS runtime
+// provides.
+//
+// WasmEmitter is intentionally narrow: it answers the same questions
+// JSEmitter does (primitive type names, zero values) so that zeroValue,
+// typeName and translateArgs don't need to know which backend is active.
+// Everything else - control flow, GC object layout, method dispatch - still
+// goes through the JS runtime shim in this first cut.
+//
+// The funcContexts of one compile share a single WasmEmitter so that they
+// agree on shim-kind IDs. That table is not guarded by a lock, so a
+// WasmEmitter is not safe for concurrent use. Use NewWasmEmitter rather than
+// a bare WasmEmitter{} literal so that table exists.
+type WasmEmitter struct {
+	// Sizes computes wasm-level struct/array layout, mirroring how the Go
+	// compiler's SizesFor("gc", "wasm") would size them.
+	Sizes types.Sizes
+
+	// shimKinds maps a "$kindXxx" name (as produced by typeKind) to the
+	// small integer the JS shim's zero-value dispatch table is indexed
+	// by. IDs are assigned on first sight and are only stable for this
+	// WasmEmitter's lifetime, i.e. one compile.
+	shimKinds map[string]int
+}
+
+// NewWasmEmitter constructs a WasmEmitter ready for use.
+func NewWasmEmitter(sizes types.Sizes) *WasmEmitter {
+	return &WasmEmitter{Sizes: sizes, shimKinds: make(map[string]int)}
+}
+
+// wasmValType is one of wasm's four value types.
+type wasmValType string
+
+const (
+	wasmI32 wasmValType = "i32"
+	wasmI64 wasmValType = "i64"
+	wasmF32 wasmValType = "f32"
+	wasmF64 wasmValType = "f64"
+)
+
+// valType returns the wasm value type used to hold a basic Go type natively.
+// 64-bit ints are i64, float32 is f32, float64 (and complex) is f64, and
+// bools, smaller ints and unsafe.Pointer are i32. Everything else (strings)
+// is represented as an i32 handle into the JS shim's object table.
+func (*WasmEmitter) valType(t *types.Basic) wasmValType {
+	switch {
+	case is64Bit(t):
+		return wasmI64
+	case t.Kind() == types.Float32:
+		return wasmF32
+	case t.Kind() == types.Float64, isComplex(t):
+		return wasmF64
+	case isBoolean(t), isInteger(t), t.Kind() == types.UnsafePointer:
+		return wasmI32
+	default: // string and friends: a handle into the JS shim.
+		return wasmI32
+	}
+}
+
+func (e *WasmEmitter) BasicTypeName(t *types.Basic) string {
+	return string(e.valType(t))
+}
+
+// ErrorTypeName and EmptyInterfaceName both describe interface values,
+// which stay GC-managed and live behind the JS shim even in wasm output.
+func (*WasmEmitter) ErrorTypeName() string      { return "$wasmShim.errorType" }
+func (*WasmEmitter) EmptyInterfaceName() string { return "$wasmShim.emptyInterfaceType" }
+
+// ZeroValue returns a WAT constant expression for natively-represented
+// types, and a call out to the JS shim for GC-managed ones.
+func (e *WasmEmitter) ZeroValue(c *funcContext, ty types.Type) string {
+	switch t := ty.Underlying().(type) {
+	case *types.Basic:
+		if t.Kind() == types.UntypedNil {
+			panic("Zero value for untyped nil.")
+		}
+		return fmt.Sprintf("(%s.const 0)", e.valType(t))
+	case *types.Array, *types.Struct:
+		// Fixed-layout aggregates still live in wasm linear memory; the
+		// caller zero-fills the region instead of asking for a value here.
+		return fmt.Sprintf(";; zero-fill %d bytes", e.Sizes.Sizeof(ty))
+	default:
+		// Slices, maps, channels, interfaces, funcs: GC-managed, ask the
+		// shim for its nil handle by kind ID.
+		return fmt.Sprintf("(call $wasmShim.zero (i32.const %d))", e.shimKindID(typeKind(ty)))
+	}
+}
+
+// Int64Literal renders a 64-bit integer constant as wasm's native i64,
+// ignoring typeName: unlike JSEmitter's $Int64 object, a wasm i64 has no
+// named constructor to call through.
+func (*WasmEmitter) Int64Literal(typeName string, hi int32, lo uint32) string {
+	return fmt.Sprintf("(i64.const %d)", int64(uint64(uint32(hi))<<32|uint64(lo)))
+}
+
+// ComplexLiteral panics: valType folds complex values onto the same scalar
+// f64 it uses for float64, which cannot hold a complex number's imaginary
+// half, so there is no sound wasm-native encoding to fall back to here.
+// Complex support needs its own GC-managed representation (as interfaces,
+// maps and the other types ZeroValue hands to the JS shim already have)
+// before this backend can claim to handle it.
+func (*WasmEmitter) ComplexLiteral(typeName string, re, im string) string {
+	panic("WasmEmitter: complex constants are not supported by this backend yet")
+}
+
+// shimKindID returns e's ID for kind, assigning the next free one the first
+// time kind is seen.
+func (e *WasmEmitter) shimKindID(kind string) int {
+	if id, ok := e.shimKinds[kind]; ok {
+		return id
+	}
+	id := len(e.shimKinds)
+	e.shimKinds[kind] = id
+	return id
+}